@@ -0,0 +1,40 @@
+package ctxrecover
+
+import (
+	"context"
+	"testing"
+)
+
+// callWithContext exists purely so ctx shows up as an argument word on the
+// stack for All() to find, the same way panicker's original demo needed a
+// frame with ctx in its argument list to scan. It must not be inlined away -
+// otherwise ctx never materializes as a frame argument at all.
+//
+// Calling a method on ctx after All() returns keeps both of its words live
+// across that call, which forces the compiler to spill them to their stack
+// home before it rather than leaving them in registers. Without that,
+// runtime.Stack prints whatever stale words sit in this frame's argument
+// slots (or flags them "0x...?", which candidateWords skips), and the scan
+// recovers some other context.Context instead of ctx.
+//
+//go:noinline
+func callWithContext(ctx context.Context) []context.Context {
+	found := All()
+	_ = ctx.Err()
+	return found
+}
+
+func TestAllRecoversContextFromStack(t *testing.T) {
+	want := context.WithValue(context.Background(), "key", "value")
+
+	found := callWithContext(want)
+	if len(found) == 0 {
+		t.Fatal("All() found no contexts on the stack")
+	}
+	for _, ctx := range found {
+		if ctx.Value("key") == "value" {
+			return
+		}
+	}
+	t.Fatalf("All() did not recover the context passed to callWithContext, got %v", found)
+}
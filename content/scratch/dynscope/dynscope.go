@@ -0,0 +1,111 @@
+// Package dynscope turns the stack-scanning trick in ctxrecover into a
+// dynamically scoped variable: a leaf function can pull a value out of
+// whichever ancestor frame stashed it in a context.Context, without that
+// context being threaded through every intermediate function signature -
+// the "abusing context" pattern from abusing-context-part-ii.go, packaged.
+//
+// This is not a replacement for passing a ctx parameter. It's what's left
+// once you can't change a signature, or you're demonstrating how far you
+// can stretch context.Context before it stops being context.Context.
+package dynscope
+
+import (
+	"context"
+	"regexp"
+	"runtime"
+	"sync"
+
+	"github.com/raidancampbell/raidancampbell.github.io.source/content/scratch/ctxrecover"
+)
+
+// shadow is a single goroutine's override stack: the most recently pushed
+// context.Context wins, same as a dynamic binding shadows the nearest
+// enclosing one.
+type shadow struct {
+	mu    sync.Mutex
+	stack []context.Context
+}
+
+// shadows maps a goroutine ID (parsed out of runtime.Stack's first line) to
+// that goroutine's shadow stack. Keyed by ID rather than goroutine-local
+// storage because Go has no goroutine-local storage to key by.
+var shadows sync.Map
+
+var goroutineIDPatt = regexp.MustCompile(`^goroutine (\d+) `)
+
+// goroutineID returns the numeric ID of the calling goroutine, scraped from
+// the "goroutine 7 [running]:" header line runtime.Stack always prints
+// first. There's no supported way to ask for this directly.
+func goroutineID() string {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	m := goroutineIDPatt.FindSubmatch(buf[:n])
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// WithOverride pushes ctx onto the calling goroutine's shadow stack for the
+// duration of fn, so any Value call fn makes - directly, or via something it
+// calls - finds ctx before falling back to the unsafe stack-scanning path.
+// Call sites and tests that already have a context should use this instead
+// of relying on ctxrecover to find one.
+func WithOverride(ctx context.Context, fn func()) {
+	id := goroutineID()
+	s, _ := shadows.LoadOrStore(id, &shadow{})
+	sh := s.(*shadow)
+
+	sh.mu.Lock()
+	sh.stack = append(sh.stack, ctx)
+	sh.mu.Unlock()
+
+	defer func() {
+		sh.mu.Lock()
+		sh.stack = sh.stack[:len(sh.stack)-1]
+		empty := len(sh.stack) == 0
+		sh.mu.Unlock()
+
+		// Goroutine IDs get reused once a goroutine exits, so a permanent
+		// entry per distinct ID ever seen would leak in a long-running
+		// process that calls WithOverride from many short-lived goroutines.
+		if empty {
+			shadows.Delete(id)
+		}
+	}()
+
+	fn()
+}
+
+func overrideValue(key any) (any, bool) {
+	v, found := shadows.Load(goroutineID())
+	if !found {
+		return nil, false
+	}
+	sh := v.(*shadow)
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	for i := len(sh.stack) - 1; i >= 0; i-- {
+		if val := sh.stack[i].Value(key); val != nil {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// Value retrieves key from the nearest ancestor context.Context it can find:
+// the calling goroutine's override stack first, then ctxrecover's unsafe
+// stack scan as a fallback. It reports false only once both are exhausted
+// without a non-nil hit.
+func Value(key any) (any, bool) {
+	if v, ok := overrideValue(key); ok {
+		return v, true
+	}
+	for _, ctx := range ctxrecover.All() {
+		if v := ctx.Value(key); v != nil {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,44 @@
+package dynscope
+
+import (
+	"context"
+	"testing"
+)
+
+// callValue exists so ctx shows up as a genuine argument word on the stack
+// for ctxrecover's fallback scan to find, the same way
+// ctxrecover_test.go's callWithContext does. It must not be inlined away,
+// and calling a method on ctx after Value returns forces the compiler to
+// spill it to its stack home rather than leaving it in a register - see
+// callWithContext's doc comment in ctxrecover_test.go for why that matters.
+//
+//go:noinline
+func callValue(ctx context.Context, key any) (any, bool) {
+	v, ok := Value(key)
+	_ = ctx.Err()
+	return v, ok
+}
+
+// TestValueFallsBackToStackScan exercises the unsafe path - no WithOverride
+// in play - that's the whole point of this package: a leaf function pulling
+// a value out of an ancestor frame's context without a ctx parameter of its
+// own.
+func TestValueFallsBackToStackScan(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "key", "value")
+
+	v, ok := callValue(ctx, "key")
+	if !ok || v != "value" {
+		t.Fatalf(`Value("key") = %v, %v; want "value", true via the stack-scan fallback`, v, ok)
+	}
+}
+
+func TestWithOverrideTakesPrecedence(t *testing.T) {
+	var got any
+	var ok bool
+	WithOverride(context.WithValue(context.Background(), "key", "override"), func() {
+		got, ok = Value("key")
+	})
+	if !ok || got != "override" {
+		t.Fatalf(`Value("key") inside WithOverride = %v, %v; want "override", true`, got, ok)
+	}
+}
@@ -0,0 +1,172 @@
+// Package workerpool replaces the longLivedWorker demo in
+// context-in-go.go, which had two real bugs: its defer cancel() accumulated
+// one deferred call per work item for the life of the range loop instead of
+// releasing each item's deadline when that item finished, and every
+// structWithCtx carried whatever deadline context the submitter built,
+// however stale it had gotten by the time a worker finally picked it up.
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned by Submit once the pool has been shut down.
+var ErrClosed = errors.New("workerpool: pool is closed")
+
+// Result is what the channel returned by Pool.Submit delivers once an item's
+// WorkFunc call returns. An expired deadline does not deliver a Result by
+// itself; a WorkFunc that ignores its ctx delays the Result until it returns.
+type Result struct {
+	Data any
+	Err  error
+}
+
+// WorkFunc is the per-item work a Pool runs. ctx carries the submitter's
+// values (trace IDs, etc.) plus a deadline scoped to this one call.
+type WorkFunc func(ctx context.Context, data any) (any, error)
+
+// Metrics is a point-in-time snapshot of a Pool's counters.
+type Metrics struct {
+	QueueDepth       int64 // items submitted but not yet picked up, including Submit calls still blocked on a full queue
+	DeadlineExceeded int64 // items whose per-item deadline expired before WorkFunc returned
+}
+
+type item struct {
+	ctx    context.Context
+	data   any
+	result chan<- Result
+}
+
+// Pool is a fixed-size fan-out worker pool. Unlike longLivedWorker, each
+// item's context.WithDeadline is scoped to a single iteration of a worker's
+// loop and cancelled at the end of that iteration - not deferred across the
+// whole loop - so nothing accumulates for the lifetime of the Pool.
+type Pool struct {
+	workFn         WorkFunc
+	perItemTimeout time.Duration
+
+	// submitMu guards against submitting to items after it's been closed by
+	// Shutdown: Submit holds the read lock for the duration of its send, and
+	// Shutdown takes the write lock before closing, so a send is never
+	// in-flight when the channel closes.
+	submitMu sync.RWMutex
+	closed   bool
+
+	items chan item
+	wg    sync.WaitGroup
+
+	queueDepth       int64
+	deadlineExceeded int64
+}
+
+// New starts a Pool of n workers running workFn, with a bounded queue of
+// queueSize items providing backpressure to submitters once it fills.
+// perItemTimeout bounds how long a single WorkFunc call is given once a
+// worker picks the item up, regardless of how long it sat queued.
+func New(n, queueSize int, perItemTimeout time.Duration, workFn WorkFunc) *Pool {
+	p := &Pool{
+		workFn:         workFn,
+		perItemTimeout: perItemTimeout,
+		items:          make(chan item, queueSize),
+	}
+	p.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go p.dispatch()
+	}
+	return p
+}
+
+func (p *Pool) dispatch() {
+	defer p.wg.Done()
+	for it := range p.items {
+		atomic.AddInt64(&p.queueDepth, -1)
+
+		ctx, cancel := context.WithDeadline(it.ctx, time.Now().Add(p.perItemTimeout))
+		data, err := p.workFn(ctx, it.data)
+		if ctx.Err() == context.DeadlineExceeded {
+			atomic.AddInt64(&p.deadlineExceeded, 1)
+		}
+		cancel() // released at the end of this iteration, never deferred across the loop
+
+		it.result <- Result{Data: data, Err: err}
+		close(it.result)
+	}
+}
+
+// Submit enqueues data for processing and returns a channel that receives
+// exactly one Result. ctx is the submitter's context: its values and
+// cancellation are inherited by the per-item deadline context a worker
+// builds once it actually starts on the item, so a trace ID or caller
+// cancellation set at submit time still reaches WorkFunc.
+//
+// Submit returns ErrClosed instead of sending once the pool has been (or is
+// being) shut down, rather than letting the send race Shutdown's close of
+// the underlying channel.
+func (p *Pool) Submit(ctx context.Context, data any) (<-chan Result, error) {
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+
+	result := make(chan Result, 1)
+	atomic.AddInt64(&p.queueDepth, 1)
+	p.items <- item{ctx: ctx, data: data, result: result}
+	return result, nil
+}
+
+// Metrics returns a snapshot of the pool's queue depth and
+// deadline-exceeded counters.
+func (p *Pool) Metrics() Metrics {
+	return Metrics{
+		QueueDepth:       atomic.LoadInt64(&p.queueDepth),
+		DeadlineExceeded: atomic.LoadInt64(&p.deadlineExceeded),
+	}
+}
+
+// Shutdown stops accepting new work and waits for queued and in-flight items
+// to drain, or for ctx to be done, whichever comes first. Submit calls that
+// arrive concurrently with or after Shutdown return ErrClosed instead of
+// panicking on the now-closed queue.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	// submitMu.Lock() can block behind an in-flight Submit that's stuck
+	// sending to a full queue (e.g. a worker ignoring its deadline), so it's
+	// raced against ctx here too rather than called directly. The close
+	// itself happens inside the goroutine that takes the lock, not after
+	// the select below: that goroutine will eventually acquire submitMu
+	// once the stuck Submit unblocks, and it must still be the one to close
+	// the queue and release the lock even if this Shutdown call has already
+	// given up - otherwise a timed-out Shutdown leaves submitMu permanently
+	// held, wedging every Submit and Shutdown that comes after it.
+	closed := make(chan struct{})
+	go func() {
+		p.submitMu.Lock()
+		if !p.closed {
+			p.closed = true
+			close(p.items)
+		}
+		p.submitMu.Unlock()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,100 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustSubmit(t *testing.T, p *Pool, ctx context.Context, data any) <-chan Result {
+	t.Helper()
+	result, err := p.Submit(ctx, data)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	return result
+}
+
+func TestSubmitReturnsResult(t *testing.T) {
+	p := New(2, 4, time.Second, func(ctx context.Context, data any) (any, error) {
+		return data, nil
+	})
+	defer p.Shutdown(context.Background())
+
+	result := <-mustSubmit(t, p, context.Background(), "hello")
+	if result.Err != nil || result.Data != "hello" {
+		t.Fatalf("got %+v, want Data=%q Err=nil", result, "hello")
+	}
+}
+
+func TestSubmitAfterShutdownReturnsErrClosed(t *testing.T) {
+	p := New(1, 1, time.Second, func(ctx context.Context, data any) (any, error) {
+		return data, nil
+	})
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	if _, err := p.Submit(context.Background(), "too late"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Submit after Shutdown: got err %v, want ErrClosed", err)
+	}
+}
+
+func TestDeadlineExceededMetric(t *testing.T) {
+	unblocked := make(chan struct{})
+	p := New(1, 1, 10*time.Millisecond, func(ctx context.Context, data any) (any, error) {
+		<-ctx.Done()
+		close(unblocked)
+		return nil, ctx.Err()
+	})
+	defer p.Shutdown(context.Background())
+
+	result := mustSubmit(t, p, context.Background(), nil)
+	<-result
+	<-unblocked
+
+	if got := p.Metrics().DeadlineExceeded; got != 1 {
+		t.Fatalf("DeadlineExceeded = %d, want 1", got)
+	}
+}
+
+// TestShutdownTimeoutDoesNotWedgeThePool reproduces a Shutdown call racing a
+// Submit that's stuck sending to a full queue: Shutdown must give up on its
+// own ctx instead of permanently holding the pool's internal lock, or every
+// Submit/Shutdown call after it blocks forever too.
+func TestShutdownTimeoutDoesNotWedgeThePool(t *testing.T) {
+	block := make(chan struct{})
+	p := New(1, 1, time.Second, func(ctx context.Context, data any) (any, error) {
+		<-block
+		return data, nil
+	})
+
+	// Fill the one worker and the one queue slot, then kick off a third
+	// Submit that has nowhere to go and will sit blocked on the channel
+	// send - the scenario that wedged submitMu before this fix.
+	mustSubmit(t, p, context.Background(), "in-flight")
+	mustSubmit(t, p, context.Background(), "queued")
+	go p.Submit(context.Background(), "stuck")
+	time.Sleep(10 * time.Millisecond) // give the third Submit time to actually block
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(shutdownCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown: got %v, want context.DeadlineExceeded", err)
+	}
+
+	close(block) // let the blocked work drain so the pool can finish closing
+
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := p.Submit(context.Background(), "after")
+		submitted <- err
+	}()
+	select {
+	case err := <-submitted:
+		t.Logf("post-timeout Submit returned: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("Submit wedged forever after a timed-out Shutdown")
+	}
+}